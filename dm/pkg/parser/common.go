@@ -142,14 +142,15 @@ func (v *tableRenameVisitor) Leave(in ast.Node) (ast.Node, bool) {
 	return in, true
 }
 
-// RenameDDLTable renames tables in ddl by given `targetTables`
-// argument `targetTables` is same with return value of FetchDDLTables
-// returned DDL is formatted like StringSingleQuotes, KeyWordUppercase and NameBackQuotes.
-func RenameDDLTable(stmt ast.StmtNode, targetTables []*filter.Table) (string, error) {
+// renameTablesInStmt applies targetTables to stmt in place, the same way
+// RenameDDLTable does, without restoring it to SQL yet. It is shared with
+// RenameDDLTableWithDialect, which needs to run a DialectRewriter between
+// the rename and the restore.
+func renameTablesInStmt(stmt ast.StmtNode, targetTables []*filter.Table) error {
 	switch stmt.(type) {
 	case ast.DDLNode:
 	default:
-		return "", terror.ErrUnknownTypeDDL.Generate(stmt)
+		return terror.ErrUnknownTypeDDL.Generate(stmt)
 	}
 
 	switch v := stmt.(type) {
@@ -165,10 +166,16 @@ func RenameDDLTable(stmt ast.StmtNode, targetTables []*filter.Table) (string, er
 		}
 		stmt.Accept(visitor)
 		if visitor.hasErr {
-			return "", terror.ErrRewriteSQL.Generate(stmt, targetTables)
+			return terror.ErrRewriteSQL.Generate(stmt, targetTables)
 		}
 	}
 
+	return nil
+}
+
+// restoreStmt restores stmt to SQL using the restore flags shared by
+// RenameDDLTable, RenameDDLTableWithDialect and SplitDDL.
+func restoreStmt(stmt ast.StmtNode) (string, error) {
 	var b []byte
 	bf := bytes.NewBuffer(b)
 	err := stmt.Restore(&format.RestoreCtx{
@@ -182,6 +189,17 @@ func RenameDDLTable(stmt ast.StmtNode, targetTables []*filter.Table) (string, er
 	return bf.String(), nil
 }
 
+// RenameDDLTable renames tables in ddl by given `targetTables`
+// argument `targetTables` is same with return value of FetchDDLTables
+// returned DDL is formatted like StringSingleQuotes, KeyWordUppercase and NameBackQuotes.
+func RenameDDLTable(stmt ast.StmtNode, targetTables []*filter.Table) (string, error) {
+	if err := renameTablesInStmt(stmt, targetTables); err != nil {
+		return "", err
+	}
+
+	return restoreStmt(stmt)
+}
+
 // SplitDDL splits multiple operations in one DDL statement into multiple DDL statements
 // returned DDL is formatted like StringSingleQuotes, KeyWordUppercase and NameBackQuotes
 // if fail to restore, it would not restore the value of `stmt` (it changes it's values if `stmt` is one of  DropTableStmt, RenameTableStmt, AlterTableStmt).
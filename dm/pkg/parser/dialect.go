@@ -0,0 +1,232 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"fmt"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+	"github.com/pingcap/tidb/pkg/parser/types"
+	"github.com/pingcap/tidb/pkg/util/filter"
+)
+
+// Dialect names a downstream SQL target whose syntax differs from TiDB's.
+type Dialect string
+
+// the set of downstream dialects DialectRewriter supports.
+const (
+	DialectMySQL57 Dialect = "mysql57"
+	DialectMySQL8  Dialect = "mysql8"
+	DialectMariaDB Dialect = "mariadb"
+	DialectANSI    Dialect = "ansi"
+)
+
+// DialectRewriter strips or translates TiDB-specific syntax from an AST so
+// the statement can be restored as valid SQL for a non-TiDB downstream.
+// Implementations mutate stmt in place and are invoked as a post-visitor
+// before stmt.Restore, after any table renaming has already happened.
+type DialectRewriter interface {
+	Rewrite(stmt ast.StmtNode) error
+}
+
+// NewDialectRewriter returns the DialectRewriter for the given dialect.
+func NewDialectRewriter(dialect Dialect) (DialectRewriter, error) {
+	switch dialect {
+	case DialectMySQL57, DialectMySQL8:
+		return &mysqlDialectRewriter{}, nil
+	case DialectMariaDB:
+		return &mariaDBDialectRewriter{}, nil
+	case DialectANSI:
+		return &ansiDialectRewriter{}, nil
+	default:
+		return nil, fmt.Errorf("parser: unsupported dialect %q", dialect)
+	}
+}
+
+// RenameDDLTableWithDialect behaves like RenameDDLTable, but additionally
+// runs dialect's DialectRewriter over stmt before restoring it, so the
+// returned SQL is valid for downstreams that do not understand TiDB-only
+// clauses such as AUTO_RANDOM, CLUSTERED, PLACEMENT POLICY, TIFLASH REPLICA
+// or SHARD_ROW_ID_BITS.
+func RenameDDLTableWithDialect(stmt ast.StmtNode, targetTables []*filter.Table, dialect Dialect) (string, error) {
+	if err := renameTablesInStmt(stmt, targetTables); err != nil {
+		return "", err
+	}
+
+	rewriter, err := NewDialectRewriter(dialect)
+	if err != nil {
+		return "", err
+	}
+	if err := rewriter.Rewrite(stmt); err != nil {
+		return "", err
+	}
+
+	return restoreStmt(stmt)
+}
+
+// tidbOnlyTableOptions are TableOption kinds with no equivalent outside
+// TiDB; mysqlDialectRewriter and mariaDBDialectRewriter drop them outright.
+var tidbOnlyTableOptions = map[ast.TableOptionType]bool{
+	ast.TableOptionShardRowID:      true,
+	ast.TableOptionPreSplitRegion:  true,
+	ast.TableOptionPlacementPolicy: true,
+}
+
+// dropTiDBOnlyTableOptions strips these TiDB-only clauses from a CREATE
+// TABLE or ALTER TABLE stmt: the table options in tidbOnlyTableOptions,
+// AUTO_RANDOM column options, the CLUSTERED / NONCLUSTERED modifier of a
+// PRIMARY KEY constraint, and ALTER TABLE ... SET TIFLASH REPLICA specs.
+// A CLUSTERED / NONCLUSTERED modifier written on a column-level PRIMARY KEY
+// option is left as it is, and other statement kinds are not changed.
+func dropTiDBOnlyTableOptions(stmt ast.StmtNode) {
+	switch v := stmt.(type) {
+	case *ast.CreateTableStmt:
+		v.Options = filterTableOptions(v.Options)
+		for _, col := range v.Cols {
+			dropAutoRandomColumnOption(col)
+		}
+		for _, cons := range v.Constraints {
+			dropClusteredIndexOption(cons)
+		}
+	case *ast.AlterTableStmt:
+		v.Specs = filterTiFlashReplicaSpecs(v.Specs)
+		for _, spec := range v.Specs {
+			spec.Options = filterTableOptions(spec.Options)
+			for _, col := range spec.NewColumns {
+				dropAutoRandomColumnOption(col)
+			}
+			if spec.Constraint != nil {
+				dropClusteredIndexOption(spec.Constraint)
+			}
+		}
+	}
+}
+
+func filterTableOptions(opts []*ast.TableOption) []*ast.TableOption {
+	kept := opts[:0]
+	for _, opt := range opts {
+		if !tidbOnlyTableOptions[opt.Tp] {
+			kept = append(kept, opt)
+		}
+	}
+	return kept
+}
+
+// dropAutoRandomColumnOption replaces a TiDB AUTO_RANDOM column option with
+// plain AUTO_INCREMENT, which every MySQL-family downstream understands and
+// which AUTO_RANDOM implies on TiDB (both require the column to be the
+// table's single integer primary key).
+func dropAutoRandomColumnOption(col *ast.ColumnDef) {
+	for i, opt := range col.Options {
+		if opt.Tp == ast.ColumnOptionAutoRandom {
+			col.Options[i] = &ast.ColumnOption{Tp: ast.ColumnOptionAutoIncrement}
+		}
+	}
+}
+
+// dropClusteredIndexOption strips the CLUSTERED / NONCLUSTERED modifier from
+// a primary-key constraint, leaving the storage engine's own default
+// clustering behavior in effect downstream.
+func dropClusteredIndexOption(cons *ast.Constraint) {
+	if cons.Tp != ast.ConstraintPrimaryKey || cons.Option == nil {
+		return
+	}
+	cons.Option.PrimaryKeyTp = ast.PrimaryKeyTypeDefault
+}
+
+// filterTiFlashReplicaSpecs drops ALTER TABLE ... SET TIFLASH REPLICA specs,
+// which configure a TiDB/TiFlash-only storage feature with no equivalent in
+// any downstream this package targets.
+func filterTiFlashReplicaSpecs(specs []*ast.AlterTableSpec) []*ast.AlterTableSpec {
+	kept := specs[:0]
+	for _, spec := range specs {
+		if spec.Tp != ast.AlterTableSetTiFlashReplica {
+			kept = append(kept, spec)
+		}
+	}
+	return kept
+}
+
+// mysqlDialectRewriter targets vanilla MySQL 5.7/8.0: it strips every
+// TiDB-only clause via dropTiDBOnlyTableOptions and does nothing else.
+type mysqlDialectRewriter struct{}
+
+func (r *mysqlDialectRewriter) Rewrite(stmt ast.StmtNode) error {
+	dropTiDBOnlyTableOptions(stmt)
+	return nil
+}
+
+// mariaDBDialectRewriter targets MariaDB: it strips the same TiDB-only
+// clauses as mysqlDialectRewriter, and additionally downgrades the JSON
+// columns of a CREATE TABLE to LONGTEXT with a CHECK(JSON_VALID(...))
+// constraint, matching the type and validation MariaDB itself uses for its
+// JSON alias. JSON columns added or modified by ALTER TABLE are not rewritten.
+type mariaDBDialectRewriter struct{}
+
+func (r *mariaDBDialectRewriter) Rewrite(stmt ast.StmtNode) error {
+	dropTiDBOnlyTableOptions(stmt)
+
+	create, ok := stmt.(*ast.CreateTableStmt)
+	if !ok {
+		return nil
+	}
+	for _, col := range create.Cols {
+		if col.Tp == nil || col.Tp.GetType() != mysql.TypeJSON {
+			continue
+		}
+		col.Tp = longTextFieldType()
+		create.Constraints = append(create.Constraints, jsonValidCheckConstraint(col.Name.Name.O))
+	}
+	return nil
+}
+
+// longTextFieldType returns LONGTEXT, not the binary LONGBLOB that
+// types.NewFieldType(mysql.TypeLongBlob) defaults to: MySQL's TEXT and BLOB
+// family share a type code and are only distinguished by charset, so a
+// non-binary charset/collation has to be set explicitly.
+func longTextFieldType() *types.FieldType {
+	ft := types.NewFieldType(mysql.TypeLongBlob)
+	ft.SetCharset(mysql.DefaultCharset)
+	ft.SetCollate(mysql.DefaultCollationName)
+	return ft
+}
+
+// jsonValidCheckConstraint builds the CHECK(JSON_VALID(column)) constraint
+// MariaDB itself attaches to its JSON alias, so downgrading the column's
+// type does not also drop JSON validation.
+func jsonValidCheckConstraint(column string) *ast.Constraint {
+	return &ast.Constraint{
+		Tp: ast.ConstraintCheck,
+		Expr: &ast.FuncCallExpr{
+			FnName: ast.NewCIStr("JSON_VALID"),
+			Args: []ast.ExprNode{
+				&ast.ColumnNameExpr{Name: &ast.ColumnName{Name: ast.NewCIStr(column)}},
+			},
+		},
+		Enforced: true,
+	}
+}
+
+// ansiDialectRewriter targets a generic ANSI/PostgreSQL downstream: it
+// strips every TiDB-only clause via dropTiDBOnlyTableOptions and leaves
+// everything else to the caller, since a full ANSI rewrite (identifier
+// quoting, type mapping) is out of scope for DDL replication and better
+// handled by a dedicated translation layer downstream of DM.
+type ansiDialectRewriter struct{}
+
+func (r *ansiDialectRewriter) Rewrite(stmt ast.StmtNode) error {
+	dropTiDBOnlyTableOptions(stmt)
+	return nil
+}
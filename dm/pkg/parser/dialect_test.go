@@ -0,0 +1,113 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	tidbparser "github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	_ "github.com/pingcap/tidb/pkg/types/parser_driver"
+	"github.com/pingcap/tidb/pkg/util/filter"
+)
+
+func parseOneStmt(t *testing.T, sql string) ast.StmtNode {
+	t.Helper()
+	p := tidbparser.New()
+	stmts, err := Parse(p, sql, "", "")
+	if err != nil {
+		t.Fatalf("parse %q: %v", sql, err)
+	}
+	if len(stmts) != 1 {
+		t.Fatalf("parse %q: expected 1 statement, got %d", sql, len(stmts))
+	}
+	return stmts[0]
+}
+
+func TestRenameDDLTableWithDialectDropsShardRowIDBits(t *testing.T) {
+	stmt := parseOneStmt(t, "CREATE TABLE t1 (id INT PRIMARY KEY) SHARD_ROW_ID_BITS = 4")
+
+	sql, err := RenameDDLTableWithDialect(stmt, []*filter.Table{{Schema: "d1", Name: "t1"}}, DialectMySQL8)
+	if err != nil {
+		t.Fatalf("RenameDDLTableWithDialect: %v", err)
+	}
+	if strings.Contains(strings.ToUpper(sql), "SHARD_ROW_ID_BITS") {
+		t.Errorf("expected SHARD_ROW_ID_BITS to be stripped, got: %s", sql)
+	}
+	if !strings.Contains(sql, "`d1`.`t1`") && !strings.Contains(sql, "`d1`") {
+		t.Errorf("expected renamed schema in output, got: %s", sql)
+	}
+}
+
+func TestRenameDDLTableWithDialectRewritesJSONForMariaDB(t *testing.T) {
+	stmt := parseOneStmt(t, "CREATE TABLE t1 (id INT PRIMARY KEY, data JSON)")
+
+	sql, err := RenameDDLTableWithDialect(stmt, []*filter.Table{{Schema: "d1", Name: "t1"}}, DialectMariaDB)
+	if err != nil {
+		t.Fatalf("RenameDDLTableWithDialect: %v", err)
+	}
+	upper := strings.ToUpper(sql)
+	if !strings.Contains(upper, "LONGTEXT") {
+		t.Errorf("expected JSON column to be downgraded to LONGTEXT, got: %s", sql)
+	}
+	if strings.Contains(upper, "LONGBLOB") {
+		t.Errorf("expected a non-binary LONGTEXT, not a binary LONGBLOB, got: %s", sql)
+	}
+	if !strings.Contains(upper, "CHECK") || !strings.Contains(upper, "JSON_VALID") {
+		t.Errorf("expected a JSON_VALID CHECK constraint on the downgraded column, got: %s", sql)
+	}
+}
+
+func TestRenameDDLTableWithDialectDropsAutoRandom(t *testing.T) {
+	stmt := parseOneStmt(t, "CREATE TABLE t1 (id BIGINT AUTO_RANDOM PRIMARY KEY)")
+
+	sql, err := RenameDDLTableWithDialect(stmt, []*filter.Table{{Schema: "d1", Name: "t1"}}, DialectMySQL8)
+	if err != nil {
+		t.Fatalf("RenameDDLTableWithDialect: %v", err)
+	}
+	if strings.Contains(strings.ToUpper(sql), "AUTO_RANDOM") {
+		t.Errorf("expected AUTO_RANDOM to be rewritten away, got: %s", sql)
+	}
+}
+
+func TestRenameDDLTableWithDialectDropsClustered(t *testing.T) {
+	stmt := parseOneStmt(t, "CREATE TABLE t1 (id INT, PRIMARY KEY (id) CLUSTERED)")
+
+	sql, err := RenameDDLTableWithDialect(stmt, []*filter.Table{{Schema: "d1", Name: "t1"}}, DialectMySQL8)
+	if err != nil {
+		t.Fatalf("RenameDDLTableWithDialect: %v", err)
+	}
+	if strings.Contains(strings.ToUpper(sql), "CLUSTERED") {
+		t.Errorf("expected CLUSTERED to be stripped from the primary key, got: %s", sql)
+	}
+}
+
+func TestRenameDDLTableWithDialectDropsTiFlashReplica(t *testing.T) {
+	stmt := parseOneStmt(t, "ALTER TABLE t1 SET TIFLASH REPLICA 1")
+
+	sql, err := RenameDDLTableWithDialect(stmt, []*filter.Table{{Schema: "d1", Name: "t1"}}, DialectMySQL8)
+	if err != nil {
+		t.Fatalf("RenameDDLTableWithDialect: %v", err)
+	}
+	if strings.Contains(strings.ToUpper(sql), "TIFLASH") {
+		t.Errorf("expected the TIFLASH REPLICA spec to be dropped, got: %s", sql)
+	}
+}
+
+func TestNewDialectRewriterRejectsUnknownDialect(t *testing.T) {
+	if _, err := NewDialectRewriter(Dialect("oracle")); err == nil {
+		t.Error("expected error for unsupported dialect")
+	}
+}
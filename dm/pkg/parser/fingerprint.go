@@ -0,0 +1,149 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/util/filter"
+	"github.com/pingcap/tiflow/dm/pkg/conn"
+)
+
+// quotedLiteralPattern matches quoted string literals so Fingerprint can
+// mask them out.
+var quotedLiteralPattern = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+
+// whitespacePattern collapses runs of whitespace produced by restoring and
+// by literal masking into a single space.
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+// Fingerprint produces a stable digest of stmt's AST, alongside the
+// normalized SQL the digest was computed from. Quoted string literals, and
+// numeric literals that are neither inside parentheses nor right after `=`
+// (see maskNumericLiterals), are replaced with `?`; keywords are uppercased
+// and whitespace is collapsed, so two DDLs that differ only in such literal
+// values or in formatting - including trivial
+// differences between what the MySQL and TiDB parsers restore - hash to the
+// same digest. This lets DM's sharding coordinator group equivalent DDLs
+// seen across shards instead of comparing raw SQL text.
+func Fingerprint(stmt ast.StmtNode) (digest string, normalized string, err error) {
+	restored, err := restoreStmt(stmt)
+	if err != nil {
+		return "", "", err
+	}
+
+	normalized = normalizeSQL(restored)
+	sum := sha256.Sum256([]byte(normalized))
+	digest = hex.EncodeToString(sum[:])
+
+	return digest, normalized, nil
+}
+
+// normalizeSQL masks out literals, uppercases keywords already restored in
+// StringSingleQuotes/KeyWordUppercase form, and collapses whitespace.
+func normalizeSQL(sql string) string {
+	masked := quotedLiteralPattern.ReplaceAllString(sql, "?")
+	masked = maskNumericLiterals(masked)
+	masked = strings.ToUpper(masked)
+	masked = whitespacePattern.ReplaceAllString(masked, " ")
+	return strings.TrimSpace(masked)
+}
+
+// maskNumericLiterals replaces free-standing digit runs with `?`, except
+// where they are a type parameter or key length inside parentheses (the
+// `10` in `VARCHAR(10)`) or the value of a `KEY=N` table option (the `10`
+// in `AUTO_INCREMENT=10`). Those digits are part of the schema's identity,
+// not a literal value, and masking them would make two genuinely different
+// ALTER/CREATE statements collide on the same digest.
+func maskNumericLiterals(sql string) string {
+	var b strings.Builder
+	depth := 0
+	inBacktick := false
+	lastNonSpace := byte(0)
+
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+
+		if inBacktick {
+			b.WriteByte(c)
+			if c == '`' {
+				inBacktick = false
+			}
+			i++
+			continue
+		}
+
+		switch {
+		case c == '`':
+			inBacktick = true
+			b.WriteByte(c)
+			lastNonSpace = c
+			i++
+		case c == '(':
+			depth++
+			b.WriteByte(c)
+			lastNonSpace = c
+			i++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+			b.WriteByte(c)
+			lastNonSpace = c
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(sql) && sql[j] >= '0' && sql[j] <= '9' {
+				j++
+			}
+			if depth == 0 && lastNonSpace != '=' {
+				b.WriteByte('?')
+			} else {
+				b.WriteString(sql[i:j])
+			}
+			lastNonSpace = sql[j-1]
+			i = j
+		default:
+			b.WriteByte(c)
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				lastNonSpace = c
+			}
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// FingerprintDDLTables returns the tables a DDL statement touches together
+// with its digest in a single pass, so shard-DDL coordination and
+// observability metrics can key on both without restoring stmt twice.
+func FingerprintDDLTables(schema string, stmt ast.StmtNode, flavor conn.LowerCaseTableNamesFlavor) ([]*filter.Table, string, error) {
+	tables, err := FetchDDLTables(schema, stmt, flavor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	digest, _, err := Fingerprint(stmt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return tables, digest, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import "testing"
+
+func TestFingerprintMasksLiteralValues(t *testing.T) {
+	a := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 INT DEFAULT 1")
+	b := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 INT DEFAULT 2")
+
+	digestA, _, err := Fingerprint(a)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+	digestB, _, err := Fingerprint(b)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	if digestA != digestB {
+		t.Errorf("expected statements differing only by a literal to share a digest, got %s != %s", digestA, digestB)
+	}
+}
+
+func TestFingerprintDistinguishesColumnWidth(t *testing.T) {
+	a := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 VARCHAR(10)")
+	b := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 VARCHAR(20)")
+
+	digestA, _, err := Fingerprint(a)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+	digestB, _, err := Fingerprint(b)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	if digestA == digestB {
+		t.Errorf("expected VARCHAR(10) and VARCHAR(20) to produce different digests, both were %s", digestA)
+	}
+}
+
+func TestFingerprintDistinguishesAutoIncrementValue(t *testing.T) {
+	a := parseOneStmt(t, "CREATE TABLE t1 (id INT PRIMARY KEY) AUTO_INCREMENT = 10")
+	b := parseOneStmt(t, "CREATE TABLE t1 (id INT PRIMARY KEY) AUTO_INCREMENT = 20")
+
+	digestA, _, err := Fingerprint(a)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+	digestB, _, err := Fingerprint(b)
+	if err != nil {
+		t.Fatalf("Fingerprint: %v", err)
+	}
+
+	if digestA == digestB {
+		t.Errorf("expected different AUTO_INCREMENT values to produce different digests, both were %s", digestA)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
+	"github.com/pingcap/tidb/pkg/util/filter"
+	"github.com/pingcap/tiflow/dm/pkg/terror"
+)
+
+// OnlineDDLStepKind identifies the role a single OnlineDDLStep plays in a
+// gh-ost/pt-osc style shadow-table migration.
+type OnlineDDLStepKind int
+
+// the ordered kinds of steps PlanOnlineDDL can emit.
+const (
+	OnlineDDLCreateGhost OnlineDDLStepKind = iota + 1
+	OnlineDDLAlterGhost
+	OnlineDDLCopyData
+	OnlineDDLRenameTable
+)
+
+// OnlineDDLStep is one statement in a shadow-table plan, together with the
+// SQL that would undo it so a caller can unwind a partially applied plan.
+type OnlineDDLStep struct {
+	Kind        OnlineDDLStepKind
+	SQL         string
+	RollbackSQL string
+}
+
+// onlineDDLGhostPrefix, onlineDDLGhostSuffix and onlineDDLOldSuffix follow
+// the gh-ost/pt-osc naming convention so operators already familiar with those tools
+// recognize the shadow tables DM creates.
+const (
+	onlineDDLGhostPrefix = "_"
+	onlineDDLGhostSuffix = "_new"
+	onlineDDLOldSuffix   = "_old"
+)
+
+// shadowSafeSpecs are the AlterTableSpec kinds PlanOnlineDDL knows how to
+// replay against a freshly created ghost table without losing data; any
+// other spec kind makes the whole ALTER unsafe to shadow.
+var shadowSafeSpecs = map[ast.AlterTableType]bool{
+	ast.AlterTableAddColumns: true,
+	ast.AlterTableAddIndex:   true,
+}
+
+// PlanOnlineDDL builds an ordered shadow-table migration plan for an ALTER
+// TABLE statement, mimicking gh-ost/pt-osc: create a ghost table, apply the
+// ALTER to it, copy rows across in primary-key-bounded chunks, then swap the
+// tables into place with a single RENAME TABLE. It is a sibling of SplitDDL
+// rather than a mode of it because its output (OnlineDDLStep, with
+// rollback SQL) serves a different consumer: a syncer applying a multi-step
+// transaction against downstream MySQL/TiDB instead of a single statement.
+//
+// The copy is emitted as a single OnlineDDLCopyData step whose two `?`
+// placeholders are the exclusive lower and inclusive upper primary-key
+// bound of one chunk; the caller binds and runs it once per chunk.
+// chunkSize is that statement's LIMIT; pkColumn must name a column whose values are usable for range chunking
+// (typically the table's primary key). If targetTables is non-nil, stmt is
+// rewritten under route rules via the same tableRenameVisitor RenameDDLTable
+// uses before the plan is built, so the plan targets the downstream table
+// a caller's route rules point at rather than stmt's own table; stmt is
+// mutated in place, same as RenameDDLTable.
+func PlanOnlineDDL(stmt *ast.AlterTableStmt, schema, pkColumn string, chunkSize int, targetTables []*filter.Table) ([]OnlineDDLStep, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("parser: chunkSize must be positive, got %d", chunkSize)
+	}
+
+	if targetTables != nil {
+		if err := renameTablesInStmt(stmt, targetTables); err != nil {
+			return nil, err
+		}
+	}
+
+	table := stmt.Table.Name.O
+	if schema == "" {
+		schema = stmt.Table.Schema.O
+	}
+	ghostTable := onlineDDLGhostPrefix + table + onlineDDLGhostSuffix
+	oldTable := onlineDDLGhostPrefix + table + onlineDDLOldSuffix
+
+	for _, spec := range stmt.Specs {
+		if !shadowSafeSpecs[spec.Tp] {
+			return nil, fmt.Errorf("parser: alter table spec %v is not safe to shadow online", spec.Tp)
+		}
+	}
+
+	tableIdent, err := restoreTableName(schema, table)
+	if err != nil {
+		return nil, err
+	}
+	ghostIdent, err := restoreTableName(schema, ghostTable)
+	if err != nil {
+		return nil, err
+	}
+	pkIdent, err := restoreColumnName(pkColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]OnlineDDLStep, 0, 4)
+
+	createGhostSQL, err := restoreCreateTableLike(schema, ghostTable, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, OnlineDDLStep{
+		Kind:        OnlineDDLCreateGhost,
+		SQL:         createGhostSQL,
+		RollbackSQL: fmt.Sprintf("DROP TABLE IF EXISTS %s", ghostIdent),
+	})
+
+	alterGhostSQL, err := restoreAlterOnTable(stmt, schema, ghostTable)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, OnlineDDLStep{
+		Kind:        OnlineDDLAlterGhost,
+		SQL:         alterGhostSQL,
+		RollbackSQL: fmt.Sprintf("DROP TABLE IF EXISTS %s", ghostIdent),
+	})
+
+	steps = append(steps, OnlineDDLStep{
+		Kind: OnlineDDLCopyData,
+		SQL: fmt.Sprintf(
+			"INSERT INTO %s SELECT * FROM %s WHERE %s > ? AND %s <= ? ORDER BY %s LIMIT %d",
+			ghostIdent, tableIdent, pkIdent, pkIdent, pkIdent, chunkSize,
+		),
+		RollbackSQL: fmt.Sprintf("DELETE FROM %s", ghostIdent),
+	})
+
+	renameSQL, err := restoreSwapRename(schema, table, oldTable, ghostTable)
+	if err != nil {
+		return nil, err
+	}
+	rollbackRenameSQL, err := restoreSwapRename(schema, table, ghostTable, oldTable)
+	if err != nil {
+		return nil, err
+	}
+	steps = append(steps, OnlineDDLStep{
+		Kind:        OnlineDDLRenameTable,
+		SQL:         renameSQL,
+		RollbackSQL: rollbackRenameSQL,
+	})
+
+	return steps, nil
+}
+
+// restoreAlterOnTable restores stmt's specs as an ALTER TABLE against
+// targetTable instead of stmt's own table, without mutating stmt.
+func restoreAlterOnTable(stmt *ast.AlterTableStmt, schema, targetTable string) (string, error) {
+	origTable := stmt.Table
+	stmt.Table = &ast.TableName{
+		Schema: ast.NewCIStr(schema),
+		Name:   ast.NewCIStr(targetTable),
+	}
+	defer func() { stmt.Table = origTable }()
+
+	return restoreStmt(stmt)
+}
+
+// restoreCreateTableLike builds and restores a `CREATE TABLE ghost LIKE
+// source` statement through the AST, the same way every other statement in
+// this package is produced, so identifiers are quoted and escaped correctly
+// instead of spliced into a format string.
+func restoreCreateTableLike(ghostSchema, ghostTable, sourceSchema, sourceTable string) (string, error) {
+	stmt := &ast.CreateTableStmt{
+		Table: &ast.TableName{
+			Schema: ast.NewCIStr(ghostSchema),
+			Name:   ast.NewCIStr(ghostTable),
+		},
+		ReferTable: &ast.TableName{
+			Schema: ast.NewCIStr(sourceSchema),
+			Name:   ast.NewCIStr(sourceTable),
+		},
+	}
+	return restoreStmt(stmt)
+}
+
+// restoreSwapRename builds and restores the two-table RENAME TABLE that
+// swaps table and ghostTable into place, moving table's current contents
+// aside as sideTable (`_t_old` on the forward path, `_t_new` to roll back).
+func restoreSwapRename(schema, table, sideTable, ghostTable string) (string, error) {
+	mk := func(name string) *ast.TableName {
+		return &ast.TableName{Schema: ast.NewCIStr(schema), Name: ast.NewCIStr(name)}
+	}
+
+	stmt := &ast.RenameTableStmt{
+		TableToTables: []*ast.TableToTable{
+			{OldTable: mk(table), NewTable: mk(sideTable)},
+			{OldTable: mk(ghostTable), NewTable: mk(table)},
+		},
+	}
+	return restoreStmt(stmt)
+}
+
+// restoreTableName restores a single qualified table name through the AST,
+// so callers get correctly quoted/escaped identifiers without depending on
+// a full statement to restore.
+func restoreTableName(schema, table string) (string, error) {
+	tn := &ast.TableName{Schema: ast.NewCIStr(schema), Name: ast.NewCIStr(table)}
+
+	var b bytes.Buffer
+	err := tn.Restore(&format.RestoreCtx{
+		Flags: format.DefaultRestoreFlags | format.RestoreTiDBSpecialComment | format.RestoreStringWithoutDefaultCharset,
+		In:    &b,
+	})
+	if err != nil {
+		return "", terror.ErrRestoreASTNode.Delegate(err)
+	}
+	return b.String(), nil
+}
+
+// restoreColumnName restores a single column name through the AST, for the
+// same reason as restoreTableName.
+func restoreColumnName(column string) (string, error) {
+	cn := &ast.ColumnName{Name: ast.NewCIStr(column)}
+
+	var b bytes.Buffer
+	err := cn.Restore(&format.RestoreCtx{
+		Flags: format.DefaultRestoreFlags | format.RestoreTiDBSpecialComment | format.RestoreStringWithoutDefaultCharset,
+		In:    &b,
+	})
+	if err != nil {
+		return "", terror.ErrRestoreASTNode.Delegate(err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/util/filter"
+)
+
+func TestPlanOnlineDDLBuildsGhostTablePlan(t *testing.T) {
+	stmt := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 INT").(*ast.AlterTableStmt)
+
+	steps, err := PlanOnlineDDL(stmt, "d1", "id", 1000, nil)
+	if err != nil {
+		t.Fatalf("PlanOnlineDDL: %v", err)
+	}
+	if len(steps) != 4 {
+		t.Fatalf("expected 4 steps, got %d", len(steps))
+	}
+
+	if steps[0].Kind != OnlineDDLCreateGhost || !strings.Contains(steps[0].SQL, "_t1_new") {
+		t.Errorf("unexpected create-ghost step: %+v", steps[0])
+	}
+	if steps[1].Kind != OnlineDDLAlterGhost || !strings.Contains(steps[1].SQL, "_t1_new") {
+		t.Errorf("unexpected alter-ghost step: %+v", steps[1])
+	}
+	if steps[2].Kind != OnlineDDLCopyData || !strings.Contains(steps[2].SQL, "_t1_new") {
+		t.Errorf("unexpected copy-data step: %+v", steps[2])
+	}
+	if steps[3].Kind != OnlineDDLRenameTable || !strings.Contains(steps[3].SQL, "_t1_old") {
+		t.Errorf("unexpected rename step: %+v", steps[3])
+	}
+}
+
+func TestPlanOnlineDDLAppliesRouteRules(t *testing.T) {
+	stmt := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 INT").(*ast.AlterTableStmt)
+
+	steps, err := PlanOnlineDDL(stmt, "", "id", 1000, []*filter.Table{{Schema: "d2", Name: "t2"}})
+	if err != nil {
+		t.Fatalf("PlanOnlineDDL: %v", err)
+	}
+	if !strings.Contains(steps[0].SQL, "`d2`") || !strings.Contains(steps[0].SQL, "_t2_new") {
+		t.Errorf("expected plan to target the routed table d2.t2, got: %s", steps[0].SQL)
+	}
+}
+
+func TestPlanOnlineDDLRejectsUnsafeSpec(t *testing.T) {
+	stmt := parseOneStmt(t, "ALTER TABLE t1 DROP COLUMN c1").(*ast.AlterTableStmt)
+
+	if _, err := PlanOnlineDDL(stmt, "d1", "id", 1000, nil); err == nil {
+		t.Fatal("expected DROP COLUMN to be rejected as unsafe to shadow")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser"
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/mysql"
+)
+
+// poolKey identifies a parser configuration in Pool's LRU, matching the
+// three inputs Parse takes besides the SQL text itself.
+type poolKey struct {
+	sqlMode   string
+	charset   string
+	collation string
+}
+
+// Pool hands out *parser.Parser instances under a bound on the number of
+// callers using one concurrently, and keeps an LRU of already-configured
+// parsers so repeat callers with the same (sqlMode, charset, collation)
+// avoid paying SetSQLMode/New costs again. It exists because DM's initial-
+// load and binlog-replay paths previously drove Parse from ad-hoc, lock-
+// guarded single parser instances; a bounded pool caps both peak
+// concurrency and peak memory.
+type Pool struct {
+	sem chan struct{}
+
+	mu     sync.Mutex
+	lruCap int
+	lru    *list.List // list of *poolEntry, front = most recently used
+	byKey  map[poolKey]*list.Element
+}
+
+type poolEntry struct {
+	key poolKey
+	p   *parser.Parser
+}
+
+// NewPool creates a Pool that admits at most maxInFlight concurrent Get
+// callers and caches up to lruSize idle parsers.
+func NewPool(maxInFlight, lruSize int) *Pool {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+	if lruSize <= 0 {
+		lruSize = 1
+	}
+
+	return &Pool{
+		sem:    make(chan struct{}, maxInFlight),
+		lruCap: lruSize,
+		lru:    list.New(),
+		byKey:  make(map[poolKey]*list.Element),
+	}
+}
+
+// Get acquires a parser configured for (sqlMode, charset, collation),
+// blocking until either one is available or ctx is done. The returned
+// parser must be returned with Put once the caller is finished with it.
+func (pl *Pool) Get(ctx context.Context, sqlMode, charset, collation string) (*parser.Parser, error) {
+	select {
+	case pl.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	key := poolKey{sqlMode: sqlMode, charset: charset, collation: collation}
+
+	pl.mu.Lock()
+	if elem, ok := pl.byKey[key]; ok {
+		pl.lru.Remove(elem)
+		delete(pl.byKey, key)
+		pl.mu.Unlock()
+		return elem.Value.(*poolEntry).p, nil
+	}
+	pl.mu.Unlock()
+
+	p := parser.New()
+	mode, err := mysql.GetSQLMode(sqlMode)
+	if err != nil {
+		<-pl.sem
+		return nil, err
+	}
+	p.SetSQLMode(mode)
+
+	return p, nil
+}
+
+// Put returns a parser previously obtained from Get back to the pool,
+// releasing one slot of in-flight capacity. If the LRU is at capacity, the
+// least recently used cached parser is discarded to make room.
+func (pl *Pool) Put(p *parser.Parser, sqlMode, charset, collation string) {
+	defer func() { <-pl.sem }()
+
+	key := poolKey{sqlMode: sqlMode, charset: charset, collation: collation}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if elem, ok := pl.byKey[key]; ok {
+		pl.lru.Remove(elem)
+		delete(pl.byKey, key)
+	}
+
+	elem := pl.lru.PushFront(&poolEntry{key: key, p: p})
+	pl.byKey[key] = elem
+
+	for pl.lru.Len() > pl.lruCap {
+		oldest := pl.lru.Back()
+		if oldest == nil {
+			break
+		}
+		pl.lru.Remove(oldest)
+		delete(pl.byKey, oldest.Value.(*poolEntry).key)
+	}
+}
+
+// StreamResult is one statement (or parse error) yielded by ParseStreaming.
+type StreamResult struct {
+	Stmt ast.StmtNode
+	Err  error
+}
+
+// ParseStreaming parses a semicolon-delimited stream of SQL statements
+// without buffering the whole input in memory, yielding one StreamResult
+// per statement on the returned channel. It is meant for DM's initial-load
+// and binlog-replay paths, which otherwise parse multi-megabyte DDL dumps
+// as a single string via Parse. The channel is closed when r is exhausted,
+// ctx is done, or a parser cannot be obtained from the pool; callers should
+// drain it and check Err on each result.
+func (pl *Pool) ParseStreaming(ctx context.Context, r io.Reader, sqlMode, charset, collation string) <-chan StreamResult {
+	out := make(chan StreamResult)
+
+	go func() {
+		defer close(out)
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		scanner.Split(splitOnSemicolon)
+
+		for scanner.Scan() {
+			sql := scanner.Text()
+			if len(trimSQL(sql)) == 0 {
+				continue
+			}
+
+			p, err := pl.Get(ctx, sqlMode, charset, collation)
+			if err != nil {
+				select {
+				case out <- StreamResult{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			stmts, err := Parse(p, sql, charset, collation)
+			pl.Put(p, sqlMode, charset, collation)
+
+			if err != nil {
+				select {
+				case out <- StreamResult{Err: err}:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+
+			for _, stmt := range stmts {
+				select {
+				case out <- StreamResult{Stmt: stmt}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			select {
+			case out <- StreamResult{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return out
+}
+
+// splitOnSemicolon is a bufio.SplitFunc that delimits statements on `;`
+// outside of single/double-quoted strings, so literal semicolons inside
+// string values do not split a statement early.
+func splitOnSemicolon(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	var inString byte
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString != 0:
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c == inString {
+				inString = 0
+			}
+		case c == '\'' || c == '"':
+			inString = c
+		case c == ';':
+			return i + 1, data[:i], nil
+		}
+	}
+
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	if atEOF {
+		return 0, nil, io.EOF
+	}
+
+	return 0, nil, nil
+}
+
+func trimSQL(sql string) string {
+	start, end := 0, len(sql)
+	for start < end && isSQLSpace(sql[start]) {
+		start++
+	}
+	for end > start && isSQLSpace(sql[end-1]) {
+		end--
+	}
+	return sql[start:end]
+}
+
+func isSQLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
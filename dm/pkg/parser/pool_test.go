@@ -0,0 +1,94 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPoolGetPutReusesCachedParser(t *testing.T) {
+	pl := NewPool(1, 1)
+	ctx := context.Background()
+
+	p1, err := pl.Get(ctx, "", "", "")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	pl.Put(p1, "", "", "")
+
+	p2, err := pl.Get(ctx, "", "", "")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if p1 != p2 {
+		t.Error("expected Get to return the parser cached by Put for the same key")
+	}
+	pl.Put(p2, "", "", "")
+}
+
+func TestPoolGetRespectsContextCancellation(t *testing.T) {
+	pl := NewPool(1, 1)
+	ctx := context.Background()
+
+	p, err := pl.Get(ctx, "", "", "")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer pl.Put(p, "", "", "")
+
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := pl.Get(cancelCtx, "", "", ""); err == nil {
+		t.Error("expected Get to fail once the pool is full and ctx is already done")
+	}
+}
+
+func TestPoolParseStreamingYieldsEachStatement(t *testing.T) {
+	pl := NewPool(2, 2)
+	ctx := context.Background()
+
+	r := strings.NewReader("CREATE TABLE t1 (id INT); CREATE TABLE t2 (id INT);")
+
+	var stmts int
+	for res := range pl.ParseStreaming(ctx, r, "", "", "") {
+		if res.Err != nil {
+			t.Fatalf("ParseStreaming: %v", res.Err)
+		}
+		stmts++
+	}
+	if stmts != 2 {
+		t.Errorf("expected 2 statements, got %d", stmts)
+	}
+}
+
+func TestPoolParseStreamingSkipsSemicolonsInsideStrings(t *testing.T) {
+	pl := NewPool(1, 1)
+	ctx := context.Background()
+
+	r := strings.NewReader(`INSERT INTO t1 (s) VALUES ('a;b');`)
+
+	var stmts int
+	for res := range pl.ParseStreaming(ctx, r, "", "", "") {
+		if res.Err != nil {
+			t.Fatalf("ParseStreaming: %v", res.Err)
+		}
+		stmts++
+	}
+	if stmts != 1 {
+		t.Errorf("expected the semicolon inside the string literal not to split the statement, got %d statements", stmts)
+	}
+}
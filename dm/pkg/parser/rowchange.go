@@ -0,0 +1,284 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tiflow/dm/pkg/conn"
+)
+
+// RowChangeOp represents the kind of change a RowChange carries.
+type RowChangeOp int
+
+// the set of operations a RowChangeDecoder can emit.
+const (
+	RowChangeInsert RowChangeOp = iota + 1
+	RowChangeUpdate
+	RowChangeDelete
+)
+
+// RawKV is a minimal representation of a binlog row event, decoupled from
+// any particular replication client so that RowChangeDecoder can be driven
+// by DM's syncer as well as other in-tree tools.
+//
+// Columns is optional: a binlog row event normally carries column values by
+// position, not by name, so callers that already know the table's column
+// layout may leave it empty and let RowChangeDecoder resolve names from its
+// schema cache instead. When set, it also refreshes that cache for the
+// table, so the next event for the same table may omit it.
+type RawKV struct {
+	Schema   string
+	Table    string
+	Columns  []string
+	Before   []interface{} // nil for RowChangeInsert
+	After    []interface{} // nil for RowChangeDelete
+	CommitTS uint64
+}
+
+// RowChange is the normalized row-change record produced by RowChangeDecoder.
+type RowChange struct {
+	Schema   string
+	Table    string
+	Columns  []string
+	Before   []interface{}
+	After    []interface{}
+	CommitTS uint64
+	Op       RowChangeOp
+}
+
+// Encoder turns a RowChange into a serialized form for a downstream sink.
+// Implementations are expected to be stateless and safe for concurrent use.
+type Encoder interface {
+	Encode(rc *RowChange) ([]byte, error)
+}
+
+// JSONEncoder encodes a RowChange as a JSON object.
+type JSONEncoder struct{}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(rc *RowChange) ([]byte, error) {
+	return json.Marshal(rc)
+}
+
+// CanonicalEncoder encodes a RowChange as a single deterministic line of
+// `schema.table op col1=v1,col2=v2 ... @commitTS`, suitable for logging or
+// for consumers that diff change streams byte-for-byte.
+type CanonicalEncoder struct{}
+
+// Encode implements Encoder.
+func (CanonicalEncoder) Encode(rc *RowChange) ([]byte, error) {
+	values := rc.After
+	if values == nil {
+		values = rc.Before
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s.%s %s ", rc.Schema, rc.Table, rowChangeOpString(rc.Op))
+	for i, col := range rc.Columns {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		var v interface{}
+		if i < len(values) {
+			v = values[i]
+		}
+		fmt.Fprintf(&sb, "%s=%v", col, v)
+	}
+	fmt.Fprintf(&sb, " @%d", rc.CommitTS)
+
+	return []byte(sb.String()), nil
+}
+
+func rowChangeOpString(op RowChangeOp) string {
+	switch op {
+	case RowChangeInsert:
+		return "INSERT"
+	case RowChangeUpdate:
+		return "UPDATE"
+	case RowChangeDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// schemaKey identifies a table in the schema cache kept by RowChangeDecoder.
+type schemaKey struct {
+	schema string
+	table  string
+}
+
+// schemaEntry is the cached column layout of a table, refreshed whenever a
+// DDL statement touching it passes through UpdateSchema.
+type schemaEntry struct {
+	columns []string
+}
+
+// RowChangeDecoder consumes the AST produced by Parse alongside raw binlog
+// row events and emits normalized RowChange records. It keeps a schema cache
+// keyed by (schema, table) so that Decode does not need a column list on
+// every call; the cache is kept current by feeding DDL statements through
+// UpdateSchema as they are replicated.
+type RowChangeDecoder struct {
+	flavor conn.LowerCaseTableNamesFlavor
+
+	mu     sync.RWMutex
+	schema map[schemaKey]*schemaEntry
+}
+
+// NewRowChangeDecoder creates a RowChangeDecoder with an empty schema cache.
+func NewRowChangeDecoder(flavor conn.LowerCaseTableNamesFlavor) *RowChangeDecoder {
+	return &RowChangeDecoder{
+		flavor: flavor,
+		schema: make(map[schemaKey]*schemaEntry),
+	}
+}
+
+// UpdateSchema updates the decoder's schema cache from a DDL statement. It
+// reuses FetchDDLTables to find the table a DDL touches (the first table
+// FetchDDLTables returns, per its own doc comment), and derives the new
+// column layout from the AST itself: a CREATE TABLE populates the cache
+// directly from its column definitions, and an ALTER TABLE's ADD COLUMN /
+// DROP COLUMN specs are applied on top of whatever is already cached for
+// that table. DDL this decoder cannot derive a column list from - an ALTER
+// on a table with nothing cached yet, an ALTER that renames or repositions
+// a column (see applyAlterSpecsToColumns), or any other DDL kind such as DROP
+// TABLE or RENAME TABLE - simply invalidates the cache entry, so the next
+// Decode call for that table requires raw.Columns until something
+// repopulates it. Statements that are not DDL are rejected.
+func (d *RowChangeDecoder) UpdateSchema(schema string, stmt ast.StmtNode) error {
+	tables, err := FetchDDLTables(schema, stmt, d.flavor)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(tables) > 0 {
+		key := schemaKey{schema: tables[0].Schema, table: tables[0].Name}
+
+		switch v := stmt.(type) {
+		case *ast.CreateTableStmt:
+			d.schema[key] = &schemaEntry{columns: columnNamesFromDefs(v.Cols)}
+			return nil
+		case *ast.AlterTableStmt:
+			if entry, ok := d.schema[key]; ok {
+				if columns, ok := applyAlterSpecsToColumns(entry.columns, v.Specs); ok {
+					d.schema[key] = &schemaEntry{columns: columns}
+					return nil
+				}
+			}
+		}
+	}
+
+	for _, t := range tables {
+		delete(d.schema, schemaKey{schema: t.Schema, table: t.Name})
+	}
+	return nil
+}
+
+// columnNamesFromDefs extracts column names, in declaration order, from a
+// CREATE TABLE's column definitions.
+func columnNamesFromDefs(defs []*ast.ColumnDef) []string {
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.Name.Name.O)
+	}
+	return names
+}
+
+// applyAlterSpecsToColumns replays an ALTER TABLE's ADD COLUMN / DROP COLUMN
+// specs against a known column list, skipping spec kinds that don't change
+// the column set (index, table option, table rename, ...). It reports false
+// when a spec renames a column (CHANGE COLUMN, RENAME COLUMN) or places one
+// with FIRST / AFTER, since the cached layout is not replayed for those.
+func applyAlterSpecsToColumns(columns []string, specs []*ast.AlterTableSpec) ([]string, bool) {
+	for _, spec := range specs {
+		if spec.Position != nil && spec.Position.Tp != ast.ColumnPositionNone {
+			return nil, false
+		}
+		switch spec.Tp {
+		case ast.AlterTableChangeColumn, ast.AlterTableRenameColumn:
+			return nil, false
+		case ast.AlterTableAddColumns:
+			for _, c := range spec.NewColumns {
+				columns = append(columns, c.Name.Name.O)
+			}
+		case ast.AlterTableDropColumn:
+			name := spec.OldColumnName.Name.L
+			for i, col := range columns {
+				if strings.EqualFold(col, name) {
+					columns = append(columns[:i:i], columns[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	return columns, true
+}
+
+// Decode converts a raw binlog row event into a RowChange. If raw carries
+// column names they are used directly and also stored in the schema cache
+// for the table; otherwise the cached column layout left by a previous
+// Decode or UpdateSchema call is used, and Decode fails if the cache has
+// nothing for the table (e.g. because UpdateSchema invalidated it and no
+// row event has supplied fresh names since).
+func (d *RowChangeDecoder) Decode(raw *RawKV) (*RowChange, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("parser: nil raw row event")
+	}
+
+	key := schemaKey{schema: raw.Schema, table: raw.Table}
+	columns := raw.Columns
+
+	d.mu.Lock()
+	if len(columns) > 0 {
+		d.schema[key] = &schemaEntry{columns: columns}
+	} else if entry, ok := d.schema[key]; ok {
+		columns = entry.columns
+	}
+	d.mu.Unlock()
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("parser: no known column layout for %s.%s, row event carried none and none is cached", raw.Schema, raw.Table)
+	}
+
+	var op RowChangeOp
+	switch {
+	case raw.Before == nil && raw.After != nil:
+		op = RowChangeInsert
+	case raw.Before != nil && raw.After != nil:
+		op = RowChangeUpdate
+	case raw.Before != nil && raw.After == nil:
+		op = RowChangeDelete
+	default:
+		return nil, fmt.Errorf("parser: row event for %s.%s has neither before nor after image", raw.Schema, raw.Table)
+	}
+
+	return &RowChange{
+		Schema:   raw.Schema,
+		Table:    raw.Table,
+		Columns:  columns,
+		Before:   raw.Before,
+		After:    raw.After,
+		CommitTS: raw.CommitTS,
+		Op:       op,
+	}, nil
+}
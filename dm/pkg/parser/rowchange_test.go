@@ -0,0 +1,163 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/pingcap/tiflow/dm/pkg/conn"
+)
+
+func TestRowChangeDecoderResolvesColumnsFromCache(t *testing.T) {
+	d := NewRowChangeDecoder(conn.LCTableNamesSensitive)
+
+	_, err := d.Decode(&RawKV{
+		Schema:  "d1",
+		Table:   "t1",
+		Columns: []string{"id", "name"},
+		After:   []interface{}{1, "a"},
+	})
+	if err != nil {
+		t.Fatalf("Decode with column names: %v", err)
+	}
+
+	rc, err := d.Decode(&RawKV{
+		Schema: "d1",
+		Table:  "t1",
+		After:  []interface{}{2, "b"},
+	})
+	if err != nil {
+		t.Fatalf("Decode without column names: %v", err)
+	}
+	if len(rc.Columns) != 2 || rc.Columns[0] != "id" || rc.Columns[1] != "name" {
+		t.Errorf("expected columns resolved from cache, got %v", rc.Columns)
+	}
+}
+
+func TestRowChangeDecoderUpdateSchemaFromCreateTable(t *testing.T) {
+	d := NewRowChangeDecoder(conn.LCTableNamesSensitive)
+
+	stmt := parseOneStmt(t, "CREATE TABLE t1 (id INT, name VARCHAR(20))")
+	if err := d.UpdateSchema("d1", stmt); err != nil {
+		t.Fatalf("UpdateSchema: %v", err)
+	}
+
+	rc, err := d.Decode(&RawKV{
+		Schema: "d1",
+		Table:  "t1",
+		After:  []interface{}{1, "a"},
+	})
+	if err != nil {
+		t.Fatalf("Decode without column names after CREATE TABLE: %v", err)
+	}
+	if len(rc.Columns) != 2 || rc.Columns[0] != "id" || rc.Columns[1] != "name" {
+		t.Errorf("expected columns derived from CREATE TABLE, got %v", rc.Columns)
+	}
+}
+
+func TestRowChangeDecoderUpdateSchemaFromAlterAddColumn(t *testing.T) {
+	d := NewRowChangeDecoder(conn.LCTableNamesSensitive)
+
+	create := parseOneStmt(t, "CREATE TABLE t1 (id INT)")
+	if err := d.UpdateSchema("d1", create); err != nil {
+		t.Fatalf("UpdateSchema(CREATE): %v", err)
+	}
+
+	alter := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN name VARCHAR(20)")
+	if err := d.UpdateSchema("d1", alter); err != nil {
+		t.Fatalf("UpdateSchema(ALTER): %v", err)
+	}
+
+	rc, err := d.Decode(&RawKV{
+		Schema: "d1",
+		Table:  "t1",
+		After:  []interface{}{1, "a"},
+	})
+	if err != nil {
+		t.Fatalf("Decode without column names after ALTER TABLE ADD COLUMN: %v", err)
+	}
+	if len(rc.Columns) != 2 || rc.Columns[0] != "id" || rc.Columns[1] != "name" {
+		t.Errorf("expected columns extended by ALTER TABLE ADD COLUMN, got %v", rc.Columns)
+	}
+}
+
+func TestRowChangeDecoderUpdateSchemaFromAlterDropColumn(t *testing.T) {
+	d := NewRowChangeDecoder(conn.LCTableNamesSensitive)
+
+	create := parseOneStmt(t, "CREATE TABLE t1 (id INT, name VARCHAR(20))")
+	if err := d.UpdateSchema("d1", create); err != nil {
+		t.Fatalf("UpdateSchema(CREATE): %v", err)
+	}
+
+	alter := parseOneStmt(t, "ALTER TABLE t1 DROP COLUMN name")
+	if err := d.UpdateSchema("d1", alter); err != nil {
+		t.Fatalf("UpdateSchema(ALTER): %v", err)
+	}
+
+	rc, err := d.Decode(&RawKV{
+		Schema: "d1",
+		Table:  "t1",
+		After:  []interface{}{1},
+	})
+	if err != nil {
+		t.Fatalf("Decode without column names after ALTER TABLE DROP COLUMN: %v", err)
+	}
+	if len(rc.Columns) != 1 || rc.Columns[0] != "id" {
+		t.Errorf("expected dropped column removed from cache, got %v", rc.Columns)
+	}
+}
+
+func TestRowChangeDecoderFailsWithoutCachedColumns(t *testing.T) {
+	d := NewRowChangeDecoder(conn.LCTableNamesSensitive)
+
+	_, err := d.Decode(&RawKV{
+		Schema: "d1",
+		Table:  "t1",
+		After:  []interface{}{1},
+	})
+	if err == nil {
+		t.Fatal("expected an error when neither the row event nor the cache has column names")
+	}
+}
+
+func TestRowChangeDecoderOpTypes(t *testing.T) {
+	d := NewRowChangeDecoder(conn.LCTableNamesSensitive)
+
+	cases := []struct {
+		name   string
+		before []interface{}
+		after  []interface{}
+		want   RowChangeOp
+	}{
+		{"insert", nil, []interface{}{1}, RowChangeInsert},
+		{"update", []interface{}{1}, []interface{}{2}, RowChangeUpdate},
+		{"delete", []interface{}{1}, nil, RowChangeDelete},
+	}
+
+	for _, c := range cases {
+		rc, err := d.Decode(&RawKV{
+			Schema:  "d1",
+			Table:   "t1",
+			Columns: []string{"id"},
+			Before:  c.before,
+			After:   c.after,
+		})
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", c.name, err)
+		}
+		if rc.Op != c.want {
+			t.Errorf("%s: expected op %v, got %v", c.name, c.want, rc.Op)
+		}
+	}
+}
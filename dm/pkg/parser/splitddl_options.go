@@ -0,0 +1,196 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+	"github.com/pingcap/tidb/pkg/parser/format"
+	"github.com/pingcap/tiflow/dm/pkg/terror"
+)
+
+// SplitOptions controls how SplitDDLWithOptions fans a multi-spec ALTER
+// TABLE out into individual statements.
+type SplitOptions struct {
+	// PreserveMultiSchemaChange keeps specs that are safe to execute
+	// together bundled into a single multi-schema-change ALTER TABLE,
+	// instead of SplitDDL's default of always splitting one-spec-per-
+	// statement. This matters when the downstream is TiDB: splitting
+	// loses the atomicity guarantee TiDB's multi-schema change provides.
+	PreserveMultiSchemaChange bool
+
+	// PerSpecTypeOverrides forces individual spec types to always be
+	// split out on their own (true) or always be considered for bundling
+	// (false), regardless of PreserveMultiSchemaChange's default grouping.
+	PerSpecTypeOverrides map[ast.AlterTableType]bool
+}
+
+// SchemaInfo is the minimal table-metadata lookup SplitDDLWithOptions needs
+// to detect spec ordering hazards within a single multi-schema-change
+// ALTER TABLE, such as an added index covering a column dropped earlier in
+// the same statement. Callers typically back this with their in-memory
+// schema tracker.
+type SchemaInfo interface {
+	// HasColumn reports whether table currently has a column named column,
+	// before any spec in the ALTER being split has been applied.
+	HasColumn(schema, table, column string) bool
+}
+
+// multiSchemaChangeSafeSpecs are spec kinds SplitDDLWithOptions is willing
+// to bundle into one multi-schema-change statement; anything else is always
+// split onto its own statement, same as SplitDDL.
+var multiSchemaChangeSafeSpecs = map[ast.AlterTableType]bool{
+	ast.AlterTableAddColumns:  true,
+	ast.AlterTableDropColumn:  true,
+	ast.AlterTableAddIndex:    true,
+	ast.AlterTableDropIndexes: true,
+}
+
+// SplitDDLWithOptions behaves like SplitDDL, except for AlterTableStmt: when
+// opts.PreserveMultiSchemaChange is set, consecutive specs that are safe to
+// execute together are kept bundled in a single ALTER TABLE instead of being
+// split one-spec-per-statement, preserving TiDB's atomic multi-schema-change
+// semantics. Unsafe combinations - e.g. dropping a column an earlier spec in
+// the same ALTER added an index on - are rejected with an error instead of
+// being silently reordered. If info is non-nil, it is consulted to tell
+// whether each column an added index covers exists before the statement
+// runs (or is added by the same statement); otherwise that check is skipped.
+func SplitDDLWithOptions(stmt ast.StmtNode, schema string, opts *SplitOptions, info SchemaInfo) ([]string, error) {
+	alter, ok := stmt.(*ast.AlterTableStmt)
+	if !ok || opts == nil || !opts.PreserveMultiSchemaChange {
+		return SplitDDL(stmt, schema)
+	}
+
+	if err := validateSpecOrderHazards(alter, info); err != nil {
+		return nil, err
+	}
+
+	groups := groupBundleableSpecs(alter.Specs, opts.PerSpecTypeOverrides)
+
+	origSpecs := alter.Specs
+	origTable := alter.Table
+	defer func() {
+		alter.Specs = origSpecs
+		alter.Table = origTable
+	}()
+
+	if alter.Table.Schema.O == "" {
+		alter.Table.Schema = ast.NewCIStr(schema)
+	}
+
+	var sqls []string
+	for _, group := range groups {
+		alter.Specs = group
+
+		var b bytes.Buffer
+		err := stmt.Restore(&format.RestoreCtx{
+			Flags: format.DefaultRestoreFlags | format.RestoreTiDBSpecialComment | format.RestoreStringWithoutDefaultCharset,
+			In:    &b,
+		})
+		if err != nil {
+			return nil, terror.ErrRestoreASTNode.Delegate(err)
+		}
+		sqls = append(sqls, b.String())
+
+		for _, spec := range group {
+			if spec.Tp == ast.AlterTableRenameTable {
+				alter.Table = spec.NewTable
+			}
+		}
+	}
+
+	return sqls, nil
+}
+
+// groupBundleableSpecs splits specs into the longest possible runs where
+// every member is safe to bundle, honoring per-type overrides.
+func groupBundleableSpecs(specs []*ast.AlterTableSpec, overrides map[ast.AlterTableType]bool) [][]*ast.AlterTableSpec {
+	var groups [][]*ast.AlterTableSpec
+	var cur []*ast.AlterTableSpec
+
+	bundleable := func(tp ast.AlterTableType) bool {
+		if v, ok := overrides[tp]; ok {
+			return !v
+		}
+		return multiSchemaChangeSafeSpecs[tp]
+	}
+
+	flush := func() {
+		if len(cur) > 0 {
+			groups = append(groups, cur)
+			cur = nil
+		}
+	}
+
+	for _, spec := range specs {
+		if !bundleable(spec.Tp) {
+			flush()
+			groups = append(groups, []*ast.AlterTableSpec{spec})
+			continue
+		}
+		cur = append(cur, spec)
+	}
+	flush()
+
+	return groups
+}
+
+// validateSpecOrderHazards rejects ALTER TABLE statements where one spec
+// drops a column another spec's index still references, regardless of
+// which of the two specs comes first in alter.Specs, since bundling them
+// into one multi-schema-change statement would silently change which one
+// wins depending on restore order.
+func validateSpecOrderHazards(alter *ast.AlterTableStmt, info SchemaInfo) error {
+	schema, table := alter.Table.Schema.O, alter.Table.Name.O
+
+	dropped := make(map[string]bool)
+	for _, spec := range alter.Specs {
+		if spec.Tp == ast.AlterTableDropColumn {
+			dropped[spec.OldColumnName.Name.L] = true
+		}
+	}
+
+	for _, spec := range alter.Specs {
+		if spec.Tp != ast.AlterTableAddIndex {
+			continue
+		}
+		for _, col := range spec.Constraint.Keys {
+			name := col.Column.Name.L
+			if dropped[name] {
+				return fmt.Errorf("parser: alter table %s.%s adds an index on column %q dropped in the same statement", schema, table, name)
+			}
+			if info != nil && !info.HasColumn(schema, table, name) && !isAddedColumn(alter, name) {
+				return fmt.Errorf("parser: alter table %s.%s adds an index on unknown column %q", schema, table, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isAddedColumn(alter *ast.AlterTableStmt, name string) bool {
+	for _, spec := range alter.Specs {
+		if spec.Tp != ast.AlterTableAddColumns {
+			continue
+		}
+		for _, col := range spec.NewColumns {
+			if col.Name.Name.L == name {
+				return true
+			}
+		}
+	}
+	return false
+}
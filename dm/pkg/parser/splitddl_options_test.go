@@ -0,0 +1,56 @@
+// Copyright 2019 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pingcap/tidb/pkg/parser/ast"
+)
+
+func TestSplitDDLWithOptionsRejectsDropThenIndexHazard(t *testing.T) {
+	stmt := parseOneStmt(t, "ALTER TABLE t1 DROP COLUMN c1, ADD INDEX idx1 (c1)").(*ast.AlterTableStmt)
+
+	_, err := SplitDDLWithOptions(stmt, "d1", &SplitOptions{PreserveMultiSchemaChange: true}, nil)
+	if err == nil {
+		t.Fatal("expected an error for dropping a column an index in the same statement still references")
+	}
+}
+
+func TestSplitDDLWithOptionsRejectsIndexThenDropHazard(t *testing.T) {
+	// the more natural ordering: ADD INDEX on an existing column, followed
+	// later in the same ALTER by DROP COLUMN of that column.
+	stmt := parseOneStmt(t, "ALTER TABLE t1 ADD INDEX idx1 (c1), DROP COLUMN c1").(*ast.AlterTableStmt)
+
+	_, err := SplitDDLWithOptions(stmt, "d1", &SplitOptions{PreserveMultiSchemaChange: true}, nil)
+	if err == nil {
+		t.Fatal("expected an error when DROP COLUMN follows ADD INDEX on the same column")
+	}
+}
+
+func TestSplitDDLWithOptionsBundlesSafeSpecs(t *testing.T) {
+	stmt := parseOneStmt(t, "ALTER TABLE t1 ADD COLUMN c1 INT, ADD COLUMN c2 INT").(*ast.AlterTableStmt)
+
+	sqls, err := SplitDDLWithOptions(stmt, "d1", &SplitOptions{PreserveMultiSchemaChange: true}, nil)
+	if err != nil {
+		t.Fatalf("SplitDDLWithOptions: %v", err)
+	}
+	if len(sqls) != 1 {
+		t.Fatalf("expected both ADD COLUMN specs to be bundled into one statement, got %d: %v", len(sqls), sqls)
+	}
+	if !strings.Contains(sqls[0], "c1") || !strings.Contains(sqls[0], "c2") {
+		t.Errorf("expected bundled statement to contain both columns, got: %s", sqls[0])
+	}
+}